@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AlertRule es una regla de vigilancia tipo "price > 70000" o
+// "change_24h < -5%" sobre un símbolo, con uno o varios canales de entrega.
+type AlertRule struct {
+	ID             string    `json:"id"`
+	Symbol         string    `json:"symbol"`
+	Condition      string    `json:"condition"`
+	WebhookURL     string    `json:"webhook_url,omitempty"`
+	Email          string    `json:"email,omitempty"`
+	TelegramChatID string    `json:"telegram_chat_id,omitempty"`
+	LastFiredAt    time.Time `json:"last_fired_at,omitempty"`
+}
+
+// alertConditionPattern reconoce condiciones del tipo "<metric> <op> <value>",
+// p. ej. "price > 70000" o "change_24h < -5%".
+var alertConditionPattern = regexp.MustCompile(`^\s*(price|change_24h)\s*(>|<|>=|<=)\s*(-?[0-9.]+)%?\s*$`)
+
+// parsedCondition es el resultado de descomponer AlertRule.Condition.
+type parsedCondition struct {
+	metric   string
+	operator string
+	value    float64
+}
+
+func parseCondition(condition string) (parsedCondition, error) {
+	matches := alertConditionPattern.FindStringSubmatch(condition)
+	if matches == nil {
+		return parsedCondition{}, fmt.Errorf("condición inválida: %q", condition)
+	}
+	value, err := strconv.ParseFloat(matches[3], 64)
+	if err != nil {
+		return parsedCondition{}, fmt.Errorf("valor inválido en condición %q: %w", condition, err)
+	}
+	return parsedCondition{metric: matches[1], operator: matches[2], value: value}, nil
+}
+
+// matches evalúa la condición contra el precio y el cambio de 24h actuales.
+func (pc parsedCondition) matches(price, change24h float64) bool {
+	var actual float64
+	switch pc.metric {
+	case "price":
+		actual = price
+	case "change_24h":
+		actual = change24h
+	}
+
+	switch pc.operator {
+	case ">":
+		return actual > pc.value
+	case "<":
+		return actual < pc.value
+	case ">=":
+		return actual >= pc.value
+	case "<=":
+		return actual <= pc.value
+	}
+	return false
+}
+
+// alertDebounceInterval evita que una regla que oscila alrededor de su umbral
+// ("flapping") notifique en cada ciclo del poller; configurable con
+// ALERT_DEBOUNCE_MINUTES.
+func alertDebounceInterval() time.Duration {
+	if raw := os.Getenv("ALERT_DEBOUNCE_MINUTES"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
+			return time.Duration(parsed) * time.Minute
+		}
+	}
+	return 15 * time.Minute
+}
+
+// evaluateAlerts recorre las reglas registradas, comprueba su condición
+// contra el último precio de la caché y dispara las notificaciones que
+// correspondan, respetando el debounce por regla.
+func evaluateAlerts(ctx context.Context, store SnapshotStore, cache *MarketCache) {
+	if store == nil {
+		return
+	}
+
+	rules, err := store.ListAlertRules(ctx)
+	if err != nil {
+		log.Printf("Error listing alert rules: %v\n", err)
+		return
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	latest := latestPricesByName(cache.Get())
+	debounce := alertDebounceInterval()
+
+	for _, rule := range rules {
+		reading, ok := latest[strings.ToUpper(strings.TrimSuffix(rule.Symbol, "USDT"))]
+		if !ok {
+			continue
+		}
+
+		condition, err := parseCondition(rule.Condition)
+		if err != nil {
+			log.Printf("Error parsing alert condition for rule %s: %v\n", rule.ID, err)
+			continue
+		}
+		if !condition.matches(reading.price, reading.change24h) {
+			continue
+		}
+		if !rule.LastFiredAt.IsZero() && time.Since(rule.LastFiredAt) < debounce {
+			continue
+		}
+
+		message := fmt.Sprintf("Alerta %s: %s (precio=%.2f, cambio_24h=%.2f%%)", rule.ID, rule.Condition, reading.price, reading.change24h)
+		deliverAlert(rule, message)
+
+		if err := store.MarkAlertFired(ctx, rule.ID, time.Now()); err != nil {
+			log.Printf("Error marking alert %s as fired: %v\n", rule.ID, err)
+		}
+	}
+}
+
+// priceReading es el último precio y cambio de 24h conocidos de un símbolo,
+// ya parseados a número para poder compararlos contra una condición.
+type priceReading struct {
+	price     float64
+	change24h float64
+}
+
+func latestPricesByName(data map[string][]CryptoInfo) map[string]priceReading {
+	latest := make(map[string]priceReading)
+	for _, items := range data {
+		for _, item := range items {
+			latest[strings.ToUpper(item.Name)] = priceReading{
+				price:     parseLenientFloat(item.Price),
+				change24h: changePercent(item),
+			}
+		}
+	}
+	return latest
+}
+
+// deliverAlert envía la notificación por todos los canales configurados en
+// la regla (webhook, email, Telegram pueden combinarse).
+func deliverAlert(rule AlertRule, message string) {
+	if rule.WebhookURL != "" {
+		if err := sendWebhookAlert(rule.WebhookURL, message); err != nil {
+			log.Printf("Error sending webhook alert for rule %s: %v\n", rule.ID, err)
+		}
+	}
+	if rule.Email != "" {
+		if err := sendEmailAlert(rule.Email, message); err != nil {
+			log.Printf("Error sending email alert for rule %s: %v\n", rule.ID, err)
+		}
+	}
+	if rule.TelegramChatID != "" {
+		if err := sendTelegramAlert(rule.TelegramChatID, message); err != nil {
+			log.Printf("Error sending telegram alert for rule %s: %v\n", rule.ID, err)
+		}
+	}
+}
+
+// validateWebhookURL comprueba que webhookURL use http/https y que ninguna
+// de las IPs a las que resuelve su host sea loopback, link-local o de rango
+// privado, para que una regla de alerta no pueda usarse como SSRF hacia
+// infraestructura interna (p. ej. el endpoint de metadatos de la nube en
+// 169.254.169.254). Se llama tanto al guardar la regla como en cada envío,
+// ya que la resolución DNS puede cambiar entre una cosa y la otra.
+func validateWebhookURL(webhookURL string) error {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil {
+		return fmt.Errorf("webhook_url inválida: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("webhook_url debe usar http o https")
+	}
+	host := parsed.Hostname()
+	if host == "" {
+		return fmt.Errorf("webhook_url debe incluir un host")
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return fmt.Errorf("no se pudo resolver el host de webhook_url: %w", err)
+	}
+	for _, ip := range ips {
+		if isDisallowedWebhookIP(ip) {
+			return fmt.Errorf("webhook_url apunta a una dirección no permitida: %s", ip)
+		}
+	}
+	return nil
+}
+
+// isDisallowedWebhookIP indica si ip pertenece a un rango que nunca debería
+// ser destino de un webhook saliente (loopback, link-local, privado o meta).
+func isDisallowedWebhookIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate()
+}
+
+func sendWebhookAlert(webhookURL, message string) error {
+	if err := validateWebhookURL(webhookURL); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]string{"message": message})
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook respondió con status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sendEmailAlert envía la notificación por SMTP usando las credenciales de
+// SMTP_HOST, SMTP_PORT, SMTP_USER, SMTP_PASSWORD y SMTP_FROM.
+func sendEmailAlert(to, message string) error {
+	host := os.Getenv("SMTP_HOST")
+	port := os.Getenv("SMTP_PORT")
+	user := os.Getenv("SMTP_USER")
+	password := os.Getenv("SMTP_PASSWORD")
+	from := os.Getenv("SMTP_FROM")
+	if host == "" || port == "" || from == "" {
+		return fmt.Errorf("SMTP_HOST, SMTP_PORT y SMTP_FROM son obligatorios para enviar emails")
+	}
+
+	addr := host + ":" + port
+	auth := smtp.PlainAuth("", user, password, host)
+	body := fmt.Sprintf("Subject: Alerta de precio\r\n\r\n%s\r\n", message)
+	return smtp.SendMail(addr, auth, from, []string{to}, []byte(body))
+}
+
+// sendTelegramAlert envía la notificación vía un bot de Telegram configurado
+// con TELEGRAM_BOT_TOKEN.
+func sendTelegramAlert(chatID, message string) error {
+	token := os.Getenv("TELEGRAM_BOT_TOKEN")
+	if token == "" {
+		return fmt.Errorf("TELEGRAM_BOT_TOKEN no está configurado")
+	}
+
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", token)
+	form := url.Values{"chat_id": {chatID}, "text": {message}}
+	resp, err := http.PostForm(apiURL, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Telegram respondió con status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// handleCreateAlert atiende POST /alerts: valida la condición y persiste la
+// nueva regla con un ID generado.
+func handleCreateAlert(store SnapshotStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "las alertas no están disponibles"})
+			return
+		}
+
+		var rule AlertRule
+		if err := c.ShouldBindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := parseCondition(rule.Condition); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if rule.WebhookURL != "" {
+			if err := validateWebhookURL(rule.WebhookURL); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		rule.ID = uuid.NewString()
+		rule.Symbol = strings.ToUpper(rule.Symbol)
+
+		if err := store.SaveAlertRule(c.Request.Context(), rule); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo guardar la alerta"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, rule)
+	}
+}
+
+// handleListAlerts atiende GET /alerts.
+func handleListAlerts(store SnapshotStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "las alertas no están disponibles"})
+			return
+		}
+
+		rules, err := store.ListAlertRules(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudieron listar las alertas"})
+			return
+		}
+		c.JSON(http.StatusOK, rules)
+	}
+}
+
+// handleGetAlert atiende GET /alerts/:id.
+func handleGetAlert(store SnapshotStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "las alertas no están disponibles"})
+			return
+		}
+
+		rule, err := store.GetAlertRule(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "alerta no encontrada"})
+			return
+		}
+		c.JSON(http.StatusOK, rule)
+	}
+}
+
+// handleUpdateAlert atiende PUT /alerts/:id.
+func handleUpdateAlert(store SnapshotStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "las alertas no están disponibles"})
+			return
+		}
+
+		id := c.Param("id")
+		if _, err := store.GetAlertRule(c.Request.Context(), id); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "alerta no encontrada"})
+			return
+		}
+
+		var rule AlertRule
+		if err := c.ShouldBindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := parseCondition(rule.Condition); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if rule.WebhookURL != "" {
+			if err := validateWebhookURL(rule.WebhookURL); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		rule.ID = id
+		rule.Symbol = strings.ToUpper(rule.Symbol)
+
+		if err := store.SaveAlertRule(c.Request.Context(), rule); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo actualizar la alerta"})
+			return
+		}
+
+		c.JSON(http.StatusOK, rule)
+	}
+}
+
+// handleDeleteAlert atiende DELETE /alerts/:id.
+func handleDeleteAlert(store SnapshotStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "las alertas no están disponibles"})
+			return
+		}
+
+		if err := store.DeleteAlertRule(c.Request.Context(), c.Param("id")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "no se pudo borrar la alerta"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
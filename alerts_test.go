@@ -0,0 +1,86 @@
+package main
+
+import "testing"
+
+func TestParseCondition(t *testing.T) {
+	cases := []struct {
+		condition string
+		wantErr   bool
+		metric    string
+		operator  string
+		value     float64
+	}{
+		{"price > 70000", false, "price", ">", 70000},
+		{"change_24h < -5%", false, "change_24h", "<", -5},
+		{"price >= 1.5", false, "price", ">=", 1.5},
+		{"volume > 100", true, "", "", 0},
+		{"price ?? 100", true, "", "", 0},
+	}
+
+	for _, tc := range cases {
+		got, err := parseCondition(tc.condition)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parseCondition(%q) expected an error, got none", tc.condition)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseCondition(%q) unexpected error: %v", tc.condition, err)
+			continue
+		}
+		if got.metric != tc.metric || got.operator != tc.operator || got.value != tc.value {
+			t.Errorf("parseCondition(%q) = %+v, want metric=%s operator=%s value=%v", tc.condition, got, tc.metric, tc.operator, tc.value)
+		}
+	}
+}
+
+func TestParsedConditionMatches(t *testing.T) {
+	cases := []struct {
+		condition string
+		price     float64
+		change    float64
+		want      bool
+	}{
+		{"price > 70000", 70001, 0, true},
+		{"price > 70000", 69999, 0, false},
+		{"change_24h < -5%", 0, -5.5, true},
+		{"change_24h <= -5%", 0, -5, true},
+	}
+
+	for _, tc := range cases {
+		pc, err := parseCondition(tc.condition)
+		if err != nil {
+			t.Fatalf("parseCondition(%q) unexpected error: %v", tc.condition, err)
+		}
+		if got := pc.matches(tc.price, tc.change); got != tc.want {
+			t.Errorf("(%q).matches(price=%v, change=%v) = %v, want %v", tc.condition, tc.price, tc.change, got, tc.want)
+		}
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	cases := []struct {
+		webhookURL string
+		wantErr    bool
+	}{
+		{"https://8.8.8.8/hooks/alert", false},
+		{"http://example.invalid", true},
+		{"ftp://8.8.8.8", true},
+		{"http://127.0.0.1/", true},
+		{"http://169.254.169.254/latest/meta-data/", true},
+		{"http://10.0.0.5/", true},
+		{"http://192.168.1.1/", true},
+		{"not a url", true},
+	}
+
+	for _, tc := range cases {
+		err := validateWebhookURL(tc.webhookURL)
+		if tc.wantErr && err == nil {
+			t.Errorf("validateWebhookURL(%q) expected an error, got none", tc.webhookURL)
+		}
+		if !tc.wantErr && err != nil {
+			t.Errorf("validateWebhookURL(%q) unexpected error: %v", tc.webhookURL, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fetchFallbackMarket obtiene el ranking desde el proveedor de respaldo
+// configurado (CoinMarketCap si hay API key, si no CoinGecko sin autenticar),
+// convertido a la divisa pedida, y lo agrupa en las mismas categorías que
+// devuelve el scraper de Binance.
+func fetchFallbackMarket(ctx context.Context, currency string) (map[string][]CryptoInfo, error) {
+	items, volumes, err := fetchFallbackItems(ctx, currency)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return categorizeFallbackResults(items, volumes), nil
+}
+
+// fetchFallbackItems delega en CoinMarketCap cuando CMC_PRO_API_KEY está
+// configurada, y en CoinGecko (sin API key) en caso contrario.
+func fetchFallbackItems(ctx context.Context, currency string) ([]CryptoInfo, map[string]float64, error) {
+	if apiKey := os.Getenv("CMC_PRO_API_KEY"); apiKey != "" {
+		return fetchCoinMarketCap(ctx, apiKey, currency)
+	}
+	return fetchCoinGecko(ctx, currency)
+}
+
+// coinGeckoMarketEntry es el subconjunto que usamos de
+// GET /api/v3/coins/markets.
+type coinGeckoMarketEntry struct {
+	Symbol                   string  `json:"symbol"`
+	Name                     string  `json:"name"`
+	Image                    string  `json:"image"`
+	CurrentPrice             float64 `json:"current_price"`
+	MarketCap                float64 `json:"market_cap"`
+	MarketCapRank            int     `json:"market_cap_rank"`
+	TotalVolume              float64 `json:"total_volume"`
+	CirculatingSupply        float64 `json:"circulating_supply"`
+	PriceChangePercentage24h float64 `json:"price_change_percentage_24h"`
+}
+
+// fetchCoinGecko consulta el endpoint público (sin API key) de mercados de
+// CoinGecko para las 100 primeras criptomonedas por capitalización.
+func fetchCoinGecko(ctx context.Context, currency string) ([]CryptoInfo, map[string]float64, error) {
+	url := fmt.Sprintf(
+		"https://api.coingecko.com/api/v3/coins/markets?vs_currency=%s&order=market_cap_desc&per_page=100&page=1",
+		strings.ToLower(currency),
+	)
+
+	var entries []coinGeckoMarketEntry
+	if err := httpGetJSON(ctx, url, &entries); err != nil {
+		return nil, nil, err
+	}
+
+	items := make([]CryptoInfo, 0, len(entries))
+	volumes := make(map[string]float64, len(entries))
+	for _, entry := range entries {
+		info := CryptoInfo{
+			Image:             entry.Image,
+			Name:              strings.ToUpper(entry.Symbol),
+			Price:             formatFiatPrice(entry.CurrentPrice),
+			Change24h:         formatChange(strconv.FormatFloat(entry.PriceChangePercentage24h, 'f', -1, 64)),
+			MarketCap:         formatFiatPrice(entry.MarketCap),
+			CirculatingSupply: strconv.FormatFloat(entry.CirculatingSupply, 'f', 0, 64),
+			Rank:              entry.MarketCapRank,
+		}
+		items = append(items, info)
+		volumes[info.Name] = entry.TotalVolume
+	}
+	return items, volumes, nil
+}
+
+// coinMarketCapResponse es el subconjunto que usamos de
+// GET /v1/cryptocurrency/listings/latest.
+type coinMarketCapResponse struct {
+	Data []struct {
+		Name              string  `json:"name"`
+		Symbol            string  `json:"symbol"`
+		CMCRank           int     `json:"cmc_rank"`
+		CirculatingSupply float64 `json:"circulating_supply"`
+		Quote             map[string]struct {
+			Price            float64 `json:"price"`
+			Volume24h        float64 `json:"volume_24h"`
+			PercentChange24h float64 `json:"percent_change_24h"`
+			MarketCap        float64 `json:"market_cap"`
+		} `json:"quote"`
+	} `json:"data"`
+}
+
+// fetchCoinMarketCap consulta el endpoint de listados de CoinMarketCap
+// autenticado con la API key configurada en CMC_PRO_API_KEY.
+func fetchCoinMarketCap(ctx context.Context, apiKey, currency string) ([]CryptoInfo, map[string]float64, error) {
+	url := fmt.Sprintf(
+		"https://pro-api.coinmarketcap.com/v1/cryptocurrency/listings/latest?convert=%s",
+		strings.ToUpper(currency),
+	)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("X-CMC_PRO_API_KEY", apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status %d from CoinMarketCap", resp.StatusCode)
+	}
+
+	var raw coinMarketCapResponse
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, nil, err
+	}
+
+	items := make([]CryptoInfo, 0, len(raw.Data))
+	volumes := make(map[string]float64, len(raw.Data))
+	for _, entry := range raw.Data {
+		quote, ok := entry.Quote[strings.ToUpper(currency)]
+		if !ok {
+			continue
+		}
+		info := CryptoInfo{
+			Name:              strings.ToUpper(entry.Symbol),
+			Price:             formatFiatPrice(quote.Price),
+			Change24h:         formatChange(strconv.FormatFloat(quote.PercentChange24h, 'f', -1, 64)),
+			MarketCap:         formatFiatPrice(quote.MarketCap),
+			CirculatingSupply: strconv.FormatFloat(entry.CirculatingSupply, 'f', 0, 64),
+			Rank:              entry.CMCRank,
+		}
+		items = append(items, info)
+		volumes[info.Name] = quote.Volume24h
+	}
+	return items, volumes, nil
+}
+
+// categorizeFallbackResults reordena el ranking del proveedor de respaldo en
+// las mismas cuatro categorías que produce el scraper de Binance: los más
+// populares (por capitalización), los que más suben, los que más bajan y los
+// de mayor volumen.
+func categorizeFallbackResults(items []CryptoInfo, volumes map[string]float64) map[string][]CryptoInfo {
+	popular := append([]CryptoInfo(nil), items...)
+	sort.SliceStable(popular, func(i, j int) bool {
+		return rankOf(popular[i]) < rankOf(popular[j])
+	})
+
+	gainers := append([]CryptoInfo(nil), items...)
+	sort.SliceStable(gainers, func(i, j int) bool {
+		return changePercent(gainers[i]) > changePercent(gainers[j])
+	})
+
+	losers := append([]CryptoInfo(nil), items...)
+	sort.SliceStable(losers, func(i, j int) bool {
+		return changePercent(losers[i]) < changePercent(losers[j])
+	})
+
+	byVolume := append([]CryptoInfo(nil), items...)
+	sort.SliceStable(byVolume, func(i, j int) bool {
+		return volumes[byVolume[i].Name] > volumes[byVolume[j].Name]
+	})
+
+	return map[string][]CryptoInfo{
+		"Populares":    topN(popular, 10),
+		"Ganadores":    topN(gainers, 10),
+		"Perdedores":   topN(losers, 10),
+		"MayorVolumen": topN(byVolume, 10),
+	}
+}
+
+func topN(items []CryptoInfo, n int) []CryptoInfo {
+	if len(items) > n {
+		return items[:n]
+	}
+	return items
+}
+
+func rankOf(info CryptoInfo) int {
+	if info.Rank == 0 {
+		return 1 << 30
+	}
+	return info.Rank
+}
+
+func changePercent(info CryptoInfo) float64 {
+	value, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimPrefix(info.Change24h, "+"), "%"), 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// formatFiatPrice formatea un importe en la divisa solicitada con dos
+// decimales, igual que hace formatChange para los porcentajes.
+func formatFiatPrice(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
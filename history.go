@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleHistory atiende GET /history?symbol=BTC&from=...&to=...&granularity=1h
+// devolviendo la serie temporal persistida por el SnapshotStore. from/to
+// aceptan timestamps RFC3339; sin ellos se usa la última semana.
+func handleHistory(store SnapshotStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "el histórico no está disponible"})
+			return
+		}
+
+		symbol := strings.ToUpper(c.Query("symbol"))
+		if symbol == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "symbol es obligatorio"})
+			return
+		}
+
+		to := time.Now()
+		if raw := c.Query("to"); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "to debe ser RFC3339"})
+				return
+			}
+			to = parsed
+		}
+
+		from := to.AddDate(0, 0, -7)
+		if raw := c.Query("from"); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from debe ser RFC3339"})
+				return
+			}
+			from = parsed
+		}
+
+		granularity := c.DefaultQuery("granularity", "raw")
+
+		points, err := store.QueryHistory(c.Request.Context(), symbol, from, to, granularity)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error consultando el histórico"})
+			return
+		}
+
+		c.JSON(http.StatusOK, points)
+	}
+}
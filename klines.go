@@ -0,0 +1,282 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// Kline representa una vela de Binance ya tipada, tal y como la devuelve
+// GET /api/v3/klines pero sin los campos que no usamos.
+type Kline struct {
+	OpenTime       int64  `json:"open_time"`
+	Open           string `json:"open"`
+	High           string `json:"high"`
+	Low            string `json:"low"`
+	Close          string `json:"close"`
+	Volume         string `json:"volume"`
+	CloseTime      int64  `json:"close_time"`
+	QuoteVolume    string `json:"quote_volume"`
+	NumberOfTrades int64  `json:"trade_count"`
+}
+
+const klineCacheTTL = 5 * time.Second
+const klineCacheCapacity = 128
+
+// klineCacheEntry guarda las velas junto con el momento en que se obtuvieron
+// para poder aplicar el TTL.
+type klineCacheEntry struct {
+	key       string
+	klines    []Kline
+	fetchedAt time.Time
+}
+
+// klineLRUCache es una caché LRU sencilla, protegida por mutex, indexada por
+// la clave "symbol:interval:limit".
+type klineLRUCache struct {
+	mutex    sync.Mutex
+	capacity int
+	ttl      time.Duration
+	items    map[string]*list.Element
+	order    *list.List
+}
+
+func newKlineLRUCache(capacity int, ttl time.Duration) *klineLRUCache {
+	return &klineLRUCache{
+		capacity: capacity,
+		ttl:      ttl,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *klineLRUCache) get(key string) ([]Kline, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*klineCacheEntry)
+	if time.Since(entry.fetchedAt) > c.ttl {
+		c.order.Remove(elem)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.klines, true
+}
+
+func (c *klineLRUCache) set(key string, klines []Kline) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*klineCacheEntry).klines = klines
+		elem.Value.(*klineCacheEntry).fetchedAt = time.Now()
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	entry := &klineCacheEntry{key: key, klines: klines, fetchedAt: time.Now()}
+	elem := c.order.PushFront(entry)
+	c.items[key] = elem
+
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		if oldest != nil {
+			c.order.Remove(oldest)
+			delete(c.items, oldest.Value.(*klineCacheEntry).key)
+		}
+	}
+}
+
+var klineCache = newKlineLRUCache(klineCacheCapacity, klineCacheTTL)
+
+// fetchKlines descarga las velas de Binance para el símbolo e intervalo dados,
+// usando la caché LRU cuando el dato todavía es fresco.
+func fetchKlines(symbol, interval string, limit int) ([]Kline, error) {
+	cacheKey := symbol + ":" + interval + ":" + strconv.Itoa(limit)
+	if cached, ok := klineCache.get(cacheKey); ok {
+		return cached, nil
+	}
+
+	url := "https://api.binance.com/api/v3/klines?symbol=" + symbol +
+		"&interval=" + interval + "&limit=" + strconv.Itoa(limit)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var raw [][]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	klines := make([]Kline, 0, len(raw))
+	for _, row := range raw {
+		kline, ok := parseKlineRow(row)
+		if !ok {
+			continue
+		}
+		klines = append(klines, kline)
+	}
+
+	klineCache.set(cacheKey, klines)
+	return klines, nil
+}
+
+// parseKlineRow convierte una fila de la respuesta de Binance en un Kline,
+// usando aserciones de tipo comprobadas (como hace BitfinexProvider en
+// providers.go) en lugar de las directas, para descartar filas con una forma
+// inesperada sin hacer panic.
+func parseKlineRow(row []interface{}) (Kline, bool) {
+	if len(row) < 9 {
+		return Kline{}, false
+	}
+
+	openTime, ok := row[0].(float64)
+	if !ok {
+		return Kline{}, false
+	}
+	open, ok := row[1].(string)
+	if !ok {
+		return Kline{}, false
+	}
+	high, ok := row[2].(string)
+	if !ok {
+		return Kline{}, false
+	}
+	low, ok := row[3].(string)
+	if !ok {
+		return Kline{}, false
+	}
+	closePrice, ok := row[4].(string)
+	if !ok {
+		return Kline{}, false
+	}
+	volume, ok := row[5].(string)
+	if !ok {
+		return Kline{}, false
+	}
+	closeTime, ok := row[6].(float64)
+	if !ok {
+		return Kline{}, false
+	}
+	quoteVolume, ok := row[7].(string)
+	if !ok {
+		return Kline{}, false
+	}
+	numberOfTrades, ok := row[8].(float64)
+	if !ok {
+		return Kline{}, false
+	}
+
+	return Kline{
+		OpenTime:       int64(openTime),
+		Open:           open,
+		High:           high,
+		Low:            low,
+		Close:          closePrice,
+		Volume:         volume,
+		CloseTime:      int64(closeTime),
+		QuoteVolume:    quoteVolume,
+		NumberOfTrades: int64(numberOfTrades),
+	}, true
+}
+
+// handleKlines atiende GET /klines?symbol=BTCUSDT&interval=1h&limit=500
+func handleKlines(c *gin.Context) {
+	symbol := strings.ToUpper(c.Query("symbol"))
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "symbol es obligatorio"})
+		return
+	}
+
+	interval := c.DefaultQuery("interval", "1h")
+
+	limit := 500
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit debe ser un entero positivo"})
+			return
+		}
+		limit = parsed
+	}
+
+	klines, err := fetchKlines(symbol, interval, limit)
+	if err != nil {
+		log.Printf("Error fetching klines for %s %s: %v\n", symbol, interval, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "no se pudieron obtener las velas"})
+		return
+	}
+
+	c.JSON(http.StatusOK, klines)
+}
+
+var klineWebsocketUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// handleKlinesStream atiende el equivalente en WebSocket de /klines: tras el
+// handshake, reenvía la última vela cada vez que se cierra una nueva.
+func handleKlinesStream(c *gin.Context) {
+	symbol := strings.ToUpper(c.Query("symbol"))
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "symbol es obligatorio"})
+		return
+	}
+	interval := c.DefaultQuery("interval", "1h")
+
+	conn, err := klineWebsocketUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Error upgrading websocket for %s %s: %v\n", symbol, interval, err)
+		return
+	}
+	defer conn.Close()
+
+	var lastCloseTime int64
+	ticker := time.NewTicker(klineCacheTTL)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		klines, err := fetchKlines(symbol, interval, 2)
+		if err != nil {
+			log.Printf("Error streaming klines for %s %s: %v\n", symbol, interval, err)
+			continue
+		}
+		if len(klines) == 0 {
+			continue
+		}
+
+		// Una vela cuyo CloseTime todavía no ha pasado sigue abierta: se
+		// reenvía la anterior, que es la última ya cerrada.
+		latest := klines[len(klines)-1]
+		if latest.CloseTime > time.Now().UnixMilli() {
+			if len(klines) < 2 {
+				continue
+			}
+			latest = klines[len(klines)-2]
+		}
+		if latest.CloseTime == lastCloseTime {
+			continue
+		}
+		lastCloseTime = latest.CloseTime
+
+		if err := conn.WriteJSON(latest); err != nil {
+			return
+		}
+	}
+}
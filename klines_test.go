@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKlineLRUCacheGetSetAndEviction(t *testing.T) {
+	cache := newKlineLRUCache(2, time.Minute)
+
+	cache.set("BTCUSDT:1h:500", []Kline{{OpenTime: 1}})
+	cache.set("ETHUSDT:1h:500", []Kline{{OpenTime: 2}})
+
+	if _, ok := cache.get("BTCUSDT:1h:500"); !ok {
+		t.Fatalf("expected BTCUSDT:1h:500 to be cached")
+	}
+
+	// A third distinct key should evict the least recently used entry
+	// (ETHUSDT:1h:500, since BTCUSDT:1h:500 was just touched by get).
+	cache.set("BTCUSDT:1h:2", []Kline{{OpenTime: 3}})
+	if _, ok := cache.get("ETHUSDT:1h:500"); ok {
+		t.Errorf("expected ETHUSDT:1h:500 to have been evicted")
+	}
+	if _, ok := cache.get("BTCUSDT:1h:2"); !ok {
+		t.Errorf("expected BTCUSDT:1h:2 to be cached")
+	}
+}
+
+func TestKlineLRUCacheExpiresByTTL(t *testing.T) {
+	cache := newKlineLRUCache(4, time.Millisecond)
+	cache.set("BTCUSDT:1h:500", []Kline{{OpenTime: 1}})
+
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := cache.get("BTCUSDT:1h:500"); ok {
+		t.Errorf("expected entry to have expired past its TTL")
+	}
+}
+
+func TestFetchKlinesCacheKeyIncludesLimit(t *testing.T) {
+	klineCache.set("BTCUSDT:1h:500", []Kline{{OpenTime: 1}})
+
+	if _, ok := klineCache.get("BTCUSDT:1h:2"); ok {
+		t.Errorf("a cache entry for limit=500 should not be visible under a different limit key")
+	}
+}
+
+func TestParseKlineRow(t *testing.T) {
+	validRow := []interface{}{
+		1.0, "100", "110", "90", "105", "12.5", 2.0, "1300", 7.0,
+	}
+
+	kline, ok := parseKlineRow(validRow)
+	if !ok {
+		t.Fatalf("parseKlineRow(valid row) returned ok=false")
+	}
+	want := Kline{OpenTime: 1, Open: "100", High: "110", Low: "90", Close: "105", Volume: "12.5", CloseTime: 2, QuoteVolume: "1300", NumberOfTrades: 7}
+	if kline != want {
+		t.Errorf("parseKlineRow(valid row) = %+v, want %+v", kline, want)
+	}
+
+	badRows := [][]interface{}{
+		{1.0, "100", "110", "90", "105", "12.5", 2.0, "1300"},       // too short
+		{1.0, "100", "110", "90", "105", "12.5", 2.0, "1300", nil},  // null instead of float64
+		{"not-a-number", "100", "110", "90", "105", "12.5", 2.0, "1300", 7.0},
+	}
+	for _, row := range badRows {
+		if _, ok := parseKlineRow(row); ok {
+			t.Errorf("parseKlineRow(%v) expected ok=false", row)
+		}
+	}
+}
@@ -23,6 +23,12 @@ type CryptoInfo struct {
 	Name      string `json:"name"`
 	Price     string `json:"price"`
 	Change24h string `json:"change_24h"`
+
+	// Campos que solo rellena el proveedor de respaldo (CoinMarketCap o
+	// CoinGecko), ya que Binance no los expone.
+	MarketCap         string `json:"market_cap,omitempty"`
+	CirculatingSupply string `json:"circulating_supply,omitempty"`
+	Rank              int    `json:"rank,omitempty"`
 }
 
 type BinancePriceInfo struct {
@@ -35,16 +41,59 @@ type BinanceTickerInfo struct {
 	PriceChangePercent string `json:"priceChangePercent"`
 }
 
+// imageBaseURL es la URL desde la que se sirven las imágenes descargadas,
+// usada por el poller en segundo plano (que no tiene una petición HTTP de
+// la que tomar el host). Configurable con la variable de entorno IMAGE_BASE_URL.
+var imageBaseURL = "http://localhost:8080"
+
 func main() {
+	if base := os.Getenv("IMAGE_BASE_URL"); base != "" {
+		imageBaseURL = base
+	}
+
 	// Configurar Gin como el enrutador
 	router := gin.Default()
 
 	// Configurar CORS
 	router.Use(corsMiddleware())
 
+	// Abrir el almacén de histórico (SQLite por defecto, Postgres si se
+	// configura STORE_DRIVER=postgres) y lanzar su job de compactación diario.
+	store, err := NewSnapshotStore()
+	if err != nil {
+		log.Printf("Error opening snapshot store, el histórico quedará deshabilitado: %v\n", err)
+		store = nil
+	} else {
+		StartCompactionJob(store)
+	}
+
+	// Arrancar el poller en segundo plano: sustituye el scraping por petición
+	// por una caché que se refresca sola y desde la que sirven todas las rutas.
+	StartPoller(marketCache, store)
+
 	// Definir la ruta
 	router.GET("/", handleRequest)
 
+	// Actualizaciones en tiempo real de la caché vía Server-Sent Events
+	router.GET("/stream", handleStream)
+
+	// Histórico de snapshots persistidos
+	router.GET("/history", handleHistory(store))
+
+	// Reglas de alertas sobre precio y variación de 24h
+	router.POST("/alerts", handleCreateAlert(store))
+	router.GET("/alerts", handleListAlerts(store))
+	router.GET("/alerts/:id", handleGetAlert(store))
+	router.PUT("/alerts/:id", handleUpdateAlert(store))
+	router.DELETE("/alerts/:id", handleDeleteAlert(store))
+
+	// Comparativa de precios entre exchanges (Binance, Coinbase, Kraken, KuCoin, Bitfinex)
+	router.GET("/compare", handleCompare)
+
+	// Velas OHLC para gráficos, con caché LRU y variante en streaming
+	router.GET("/klines", handleKlines)
+	router.GET("/klines/stream", handleKlinesStream)
+
 	// Servir archivos estáticos (imágenes) desde la carpeta local
 	router.Static("/images", "./images")
 
@@ -53,7 +102,41 @@ func main() {
 	log.Fatal(router.Run(":8080"))
 }
 
+// handleRequest ya no scrapea Binance en cada petición: sirve directamente
+// el último resultado que el poller en segundo plano dejó en la caché. Si la
+// caché todavía está vacía (p. ej. justo tras arrancar) o el cliente pide una
+// divisa distinta de USDT o USD, recurre al proveedor de respaldo (CMC/CoinGecko).
 func handleRequest(c *gin.Context) {
+	currency := strings.ToUpper(c.DefaultQuery("currency", "USDT"))
+
+	data := marketCache.Get()
+	if isEmptyMarketData(data) || (currency != "USDT" && currency != "USD") {
+		fallbackData, err := fetchFallbackMarket(c.Request.Context(), currency)
+		if err != nil {
+			log.Printf("Error fetching fallback market data: %v\n", err)
+		} else if fallbackData != nil {
+			data = fallbackData
+		}
+	}
+
+	c.JSON(http.StatusOK, data)
+}
+
+// isEmptyMarketData indica si la caché todavía no tiene ninguna categoría con datos.
+func isEmptyMarketData(data map[string][]CryptoInfo) bool {
+	for _, items := range data {
+		if len(items) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// scrapeRankings hace el scraping de la página de rankings de Binance y
+// devuelve los resultados agrupados por categoría, combinados con los
+// precios y cambios de 24h obtenidos vía REST. Lo usa el poller en segundo
+// plano en lugar de ejecutarse en cada petición HTTP.
+func scrapeRankings() map[string][]CryptoInfo {
 	// URL a scrapear
 	url := "https://www.binance.com/es/markets/trading_data/rankings"
 
@@ -65,7 +148,6 @@ func handleRequest(c *gin.Context) {
 
 	// Variables para almacenar los resultados
 	var allResults []CryptoInfo
-	var groupedResults [][]CryptoInfo
 	var mutex sync.Mutex
 
 	// Obtener los precios y cambios de la API de Binance
@@ -97,13 +179,8 @@ func handleRequest(c *gin.Context) {
 					if !imageExists(imageFilename) {
 						downloadImage(info.Image, imageFilename)
 					}
-					// Obtener el esquema (http o https) de la solicitud actual
-					scheme := "http"
-					if c.Request.TLS != nil {
-						scheme = "https"
-					}
 					// Reemplazar la URL de la imagen con la ruta local
-					info.Image = scheme + "://" + c.Request.Host + "/images/" + imageFilename
+					info.Image = imageBaseURL + "/images/" + imageFilename
 				}
 
 				mutex.Lock()
@@ -118,25 +195,39 @@ func handleRequest(c *gin.Context) {
 		log.Printf("Request URL: %s failed with response: %v\nError: %s\n", r.Request.URL, r, err)
 	})
 
-	// Visitar la URL y ejecutar el scraping cada vez que se realiza una solicitud
+	// Visitar la URL y ejecutar el scraping
 	err := collyCollector.Visit(url)
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("Error scraping rankings: %v\n", err)
+		return nil
 	}
 
 	// Dividir los resultados en grupos de 10 objetos y ordenarlos según las categorías
-	groupedResults = categorizeResults(allResults)
+	groupedResults := categorizeResults(allResults)
+	if len(groupedResults) < 4 {
+		return nil
+	}
 
 	// Mapa para almacenar los resultados agrupados por categoría
-	categorizedResults := map[string][]CryptoInfo{
+	return map[string][]CryptoInfo{
 		"Populares":    groupedResults[0],
 		"Ganadores":    groupedResults[1],
 		"Perdedores":   groupedResults[2],
 		"MayorVolumen": groupedResults[3],
 	}
+}
 
-	// Devolver los resultados como JSON
-	c.JSON(http.StatusOK, categorizedResults)
+// handleCompare agrega los precios de BTC, ETH, etc. entre varios exchanges
+// y devuelve, por símbolo canónico, el precio en cada venue junto con el VWAP.
+func handleCompare(c *gin.Context) {
+	results, errs := FetchAll(c.Request.Context(), DefaultProviders())
+	for _, err := range errs {
+		log.Printf("Error fetching provider: %v\n", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"prices": results,
+	})
 }
 
 // Función para dividir y agrupar los resultados en grupos de 10 objetos
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const rankingsPollInterval = 60 * time.Second
+
+// MarketCache almacena en memoria, de forma segura para varias goroutines,
+// el último resultado del scraping de rankings y notifica a los suscriptores
+// de /stream cada vez que cambia.
+type MarketCache struct {
+	mu   sync.RWMutex
+	data map[string][]CryptoInfo
+
+	subsMu sync.Mutex
+	subs   map[chan []byte]struct{}
+}
+
+// marketCache es la caché compartida que rellena el poller en segundo plano
+// y de la que sirven handleRequest y handleStream.
+var marketCache = &MarketCache{
+	data: make(map[string][]CryptoInfo),
+	subs: make(map[chan []byte]struct{}),
+}
+
+// Get devuelve una copia profunda de los últimos resultados cacheados: tanto
+// el mapa como cada slice de CryptoInfo se copian para que el llamador nunca
+// comparta el array subyacente que UpdatePrice pueda seguir mutando.
+func (mc *MarketCache) Get() map[string][]CryptoInfo {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	copyData := make(map[string][]CryptoInfo, len(mc.data))
+	for category, items := range mc.data {
+		copyData[category] = append([]CryptoInfo(nil), items...)
+	}
+	return copyData
+}
+
+// Set reemplaza los resultados cacheados y avisa a los suscriptores de /stream.
+func (mc *MarketCache) Set(data map[string][]CryptoInfo) {
+	mc.mu.Lock()
+	mc.data = data
+	mc.mu.Unlock()
+
+	mc.broadcast(data)
+}
+
+// UpdatePrice actualiza el precio y el cambio de 24h de un símbolo ya presente
+// en la caché, sin necesidad de repetir el scraping de rankings completo. Como
+// Get puede haber entregado el slice de una categoría a otra goroutine (p. ej.
+// una respuesta JSON en curso o evaluateAlerts), construye slices nuevos en
+// lugar de mutar los existentes in situ.
+func (mc *MarketCache) UpdatePrice(name, price, change24h string) {
+	mc.mu.Lock()
+	changed := false
+	next := make(map[string][]CryptoInfo, len(mc.data))
+	for category, items := range mc.data {
+		updated := items
+		copied := false
+		for i := range items {
+			if !strings.EqualFold(items[i].Name, name) {
+				continue
+			}
+			if !copied {
+				updated = append([]CryptoInfo(nil), items...)
+				copied = true
+			}
+			updated[i].Price = price
+			updated[i].Change24h = change24h
+			changed = true
+		}
+		next[category] = updated
+	}
+	if changed {
+		mc.data = next
+	}
+	snapshot := mc.data
+	mc.mu.Unlock()
+
+	if changed {
+		mc.broadcast(snapshot)
+	}
+}
+
+// Subscribe registra un nuevo cliente de /stream y devuelve el canal por el
+// que recibirá las actualizaciones, junto con una función para darse de baja.
+func (mc *MarketCache) Subscribe() (chan []byte, func()) {
+	ch := make(chan []byte, 8)
+
+	mc.subsMu.Lock()
+	mc.subs[ch] = struct{}{}
+	mc.subsMu.Unlock()
+
+	unsubscribe := func() {
+		mc.subsMu.Lock()
+		delete(mc.subs, ch)
+		close(ch)
+		mc.subsMu.Unlock()
+	}
+	return ch, unsubscribe
+}
+
+func (mc *MarketCache) broadcast(data map[string][]CryptoInfo) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error marshaling market cache update: %v\n", err)
+		return
+	}
+
+	mc.subsMu.Lock()
+	defer mc.subsMu.Unlock()
+	for ch := range mc.subs {
+		select {
+		case ch <- payload:
+		default:
+			// El suscriptor va con retraso; se descarta la actualización en
+			// lugar de bloquear al resto de clientes.
+		}
+	}
+}
+
+// StartPoller arranca en segundo plano el refresco periódico de los rankings
+// y la ingesta en streaming de los precios en tiempo real de Binance. Si se
+// pasa un store, cada ciclo de scraping también se persiste para el histórico.
+func StartPoller(cache *MarketCache, store SnapshotStore) {
+	go pollRankings(cache, store)
+	go pollTickerStream(cache)
+}
+
+// pollRankings repite el scraping de la página de rankings cada
+// rankingsPollInterval, publica el resultado en la caché compartida y, si hay
+// un SnapshotStore configurado, lo persiste para /history.
+func pollRankings(cache *MarketCache, store SnapshotStore) {
+	refresh := func() {
+		data := scrapeRankings()
+		if data == nil {
+			return
+		}
+		cache.Set(data)
+		persistSnapshot(store, data)
+		evaluateAlerts(context.Background(), store, cache)
+	}
+
+	refresh()
+
+	ticker := time.NewTicker(rankingsPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		refresh()
+	}
+}
+
+// persistSnapshot vuelca todas las categorías del ciclo de scraping actual al
+// SnapshotStore, si hay uno configurado.
+func persistSnapshot(store SnapshotStore, data map[string][]CryptoInfo) {
+	if store == nil {
+		return
+	}
+
+	var all []CryptoInfo
+	for _, items := range data {
+		all = append(all, items...)
+	}
+	if len(all) == 0 {
+		return
+	}
+
+	if err := store.SaveSnapshot(context.Background(), all, time.Now()); err != nil {
+		log.Printf("Error saving snapshot to store: %v\n", err)
+	}
+}
+
+// binanceTickerArrayEvent es la forma abreviada que usa el stream
+// wss://stream.binance.com:9443/ws/!ticker@arr para cada símbolo.
+type binanceTickerArrayEvent struct {
+	Symbol             string `json:"s"`
+	LastPrice          string `json:"c"`
+	PriceChangePercent string `json:"P"`
+}
+
+// pollTickerStream mantiene una conexión WebSocket con el stream agregado de
+// tickers de Binance y actualiza la caché en cuanto llega un precio nuevo,
+// reconectando con backoff si la conexión se cae.
+func pollTickerStream(cache *MarketCache) {
+	const url = "wss://stream.binance.com:9443/ws/!ticker@arr"
+	backoff := time.Second
+
+	for {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			log.Printf("Error connecting to Binance ticker stream: %v\n", err)
+			time.Sleep(backoff)
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = time.Second
+
+		for {
+			var events []binanceTickerArrayEvent
+			if err := conn.ReadJSON(&events); err != nil {
+				log.Printf("Error reading from Binance ticker stream: %v\n", err)
+				break
+			}
+			for _, event := range events {
+				if !strings.HasSuffix(event.Symbol, "USDT") {
+					continue
+				}
+				name := strings.TrimSuffix(event.Symbol, "USDT")
+				cache.UpdatePrice(name, event.LastPrice, formatChange(event.PriceChangePercent))
+			}
+		}
+		conn.Close()
+	}
+}
+
+// handleStream atiende GET /stream: envía el estado actual de la caché y a
+// partir de ahí retransmite cada actualización como un evento SSE.
+func handleStream(c *gin.Context) {
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "streaming no soportado"})
+		return
+	}
+
+	updates, unsubscribe := marketCache.Subscribe()
+	defer unsubscribe()
+
+	if initial, err := json.Marshal(marketCache.Get()); err == nil {
+		c.Writer.Write([]byte("data: " + string(initial) + "\n\n"))
+		flusher.Flush()
+	}
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case payload, ok := <-updates:
+			if !ok {
+				return
+			}
+			c.Writer.Write([]byte("data: " + string(payload) + "\n\n"))
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ExchangeTicker representa el precio de un par en un exchange concreto,
+// ya normalizado a la notación canónica BASE-QUOTE (p. ej. "BTC-USDT").
+type ExchangeTicker struct {
+	Exchange string
+	Symbol   string
+	Price    float64
+	Volume   float64
+}
+
+// MarketDataProvider lo implementa cada exchange soportado para poder
+// agregarlos de forma intercambiable.
+type MarketDataProvider interface {
+	Name() string
+	FetchTickers(ctx context.Context) ([]ExchangeTicker, error)
+}
+
+// AggregatedPrice agrupa el precio de un mismo par en todos los exchanges
+// donde se cotiza, junto con el precio medio ponderado por volumen.
+type AggregatedPrice struct {
+	Symbol    string             `json:"symbol"`
+	Prices    map[string]float64 `json:"prices"`
+	VWAP      float64            `json:"vwap"`
+	Exchanges int                `json:"exchanges"`
+}
+
+// normalizeSymbol traduce la notación propia de cada exchange a la forma
+// canónica BASE-QUOTE (p. ej. "XBTUSD" -> "BTC-USD", "BTC/USDT" -> "BTC-USDT").
+func normalizeSymbol(raw string) string {
+	s := strings.ToUpper(raw)
+	s = strings.ReplaceAll(s, "/", "-")
+	if strings.Contains(s, "-") {
+		if s == "XBT-USD" {
+			return "BTC-USD"
+		}
+		return strings.ReplaceAll(s, "XBT-", "BTC-")
+	}
+
+	quotes := []string{"USDT", "USDC", "BUSD", "USD", "EUR", "GBP"}
+	for _, quote := range quotes {
+		if strings.HasSuffix(s, quote) && len(s) > len(quote) {
+			base := strings.TrimSuffix(s, quote)
+			if base == "XBT" {
+				base = "BTC"
+			}
+			return base + "-" + quote
+		}
+	}
+	return s
+}
+
+// FetchAll consulta a todos los providers en paralelo y devuelve los
+// resultados agregados por símbolo canónico con su VWAP.
+func FetchAll(ctx context.Context, providers []MarketDataProvider) (map[string]*AggregatedPrice, []error) {
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+
+	results := make(map[string]*AggregatedPrice)
+	volumesBySymbol := make(map[string]map[string]float64)
+	var errs []error
+
+	for _, provider := range providers {
+		wg.Add(1)
+		go func(p MarketDataProvider) {
+			defer wg.Done()
+			tickers, err := p.FetchTickers(ctx)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+				return
+			}
+
+			for _, t := range tickers {
+				agg, ok := results[t.Symbol]
+				if !ok {
+					agg = &AggregatedPrice{Symbol: t.Symbol, Prices: make(map[string]float64)}
+					results[t.Symbol] = agg
+				}
+				agg.Prices[t.Exchange] = t.Price
+				if volumesBySymbol[t.Symbol] == nil {
+					volumesBySymbol[t.Symbol] = make(map[string]float64)
+				}
+				volumesBySymbol[t.Symbol][t.Exchange] = t.Volume
+			}
+		}(provider)
+	}
+
+	wg.Wait()
+
+	// Ponderar el VWAP por el volumen capturado en el mismo fan-out que los
+	// precios, para que ambos vengan de la misma respuesta de cada exchange.
+	for symbol, agg := range results {
+		var priceVolumeSum, volumeSum float64
+		for exchange, price := range agg.Prices {
+			volume := volumesBySymbol[symbol][exchange]
+			priceVolumeSum += price * volume
+			volumeSum += volume
+		}
+		if volumeSum > 0 {
+			agg.VWAP = priceVolumeSum / volumeSum
+		} else if len(agg.Prices) > 0 {
+			// Sin datos de volumen: promedio simple como alternativa razonable.
+			var sum float64
+			for _, price := range agg.Prices {
+				sum += price
+			}
+			agg.VWAP = sum / float64(len(agg.Prices))
+		}
+		agg.Exchanges = len(agg.Prices)
+	}
+
+	return results, errs
+}
+
+// DefaultProviders devuelve los adaptadores de todos los exchanges soportados.
+func DefaultProviders() []MarketDataProvider {
+	return []MarketDataProvider{
+		&BinanceProvider{},
+		&CoinbaseProvider{},
+		&KrakenProvider{},
+		&KuCoinProvider{},
+		&BitfinexProvider{},
+	}
+}
+
+func httpGetJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// BinanceProvider implementa MarketDataProvider usando el ticker REST de Binance.
+type BinanceProvider struct{}
+
+func (p *BinanceProvider) Name() string { return "binance" }
+
+func (p *BinanceProvider) FetchTickers(ctx context.Context) ([]ExchangeTicker, error) {
+	var raw []struct {
+		Symbol string `json:"symbol"`
+		Price  string `json:"lastPrice"`
+		Volume string `json:"volume"`
+	}
+	if err := httpGetJSON(ctx, "https://api.binance.com/api/v3/ticker/24hr", &raw); err != nil {
+		return nil, err
+	}
+
+	tickers := make([]ExchangeTicker, 0, len(raw))
+	for _, item := range raw {
+		price, err := strconv.ParseFloat(item.Price, 64)
+		if err != nil {
+			continue
+		}
+		volume, _ := strconv.ParseFloat(item.Volume, 64)
+		tickers = append(tickers, ExchangeTicker{
+			Exchange: p.Name(),
+			Symbol:   normalizeSymbol(item.Symbol),
+			Price:    price,
+			Volume:   volume,
+		})
+	}
+	return tickers, nil
+}
+
+// CoinbaseProvider implementa MarketDataProvider usando la API pública de Coinbase Exchange.
+type CoinbaseProvider struct{}
+
+func (p *CoinbaseProvider) Name() string { return "coinbase" }
+
+// coinbaseStatsConcurrency limita cuántas peticiones a /products/{id}/stats
+// se hacen en paralelo, ya que Coinbase Exchange lista varios cientos de
+// productos y lanzarlas todas a la vez saturaría tanto el cliente como la API.
+const coinbaseStatsConcurrency = 20
+
+func (p *CoinbaseProvider) FetchTickers(ctx context.Context) ([]ExchangeTicker, error) {
+	var products []struct {
+		ID string `json:"id"`
+	}
+	if err := httpGetJSON(ctx, "https://api.exchange.coinbase.com/products", &products); err != nil {
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	tickers := make([]ExchangeTicker, 0, len(products))
+	sem := make(chan struct{}, coinbaseStatsConcurrency)
+
+	for _, product := range products {
+		wg.Add(1)
+		go func(productID string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			var stats struct {
+				Last   string `json:"last"`
+				Volume string `json:"volume"`
+			}
+			url := fmt.Sprintf("https://api.exchange.coinbase.com/products/%s/stats", productID)
+			if err := httpGetJSON(ctx, url, &stats); err != nil {
+				return
+			}
+			price, err := strconv.ParseFloat(stats.Last, 64)
+			if err != nil {
+				return
+			}
+			volume, _ := strconv.ParseFloat(stats.Volume, 64)
+
+			mutex.Lock()
+			tickers = append(tickers, ExchangeTicker{
+				Exchange: p.Name(),
+				Symbol:   normalizeSymbol(productID),
+				Price:    price,
+				Volume:   volume,
+			})
+			mutex.Unlock()
+		}(product.ID)
+	}
+
+	wg.Wait()
+	return tickers, nil
+}
+
+// krakenAssetPrefixPattern reconoce los pares que Kraken devuelve con sus
+// códigos de activo internos (X para cripto, Z para fiat), p. ej. "XXBTZUSD"
+// o "XETHZUSD", en lugar del par consultado ("XBTUSD").
+var krakenAssetPrefixPattern = regexp.MustCompile(`^[XZ]([A-Z]{3,4})[XZ]([A-Z]{3,4})$`)
+
+// stripKrakenAssetPrefixes normaliza un par con códigos internos de Kraken a
+// su forma sin prefijo (p. ej. "XXBTZUSD" -> "XBTUSD") para que
+// normalizeSymbol pueda seguir el mismo camino que el resto de exchanges.
+func stripKrakenAssetPrefixes(pair string) string {
+	if m := krakenAssetPrefixPattern.FindStringSubmatch(pair); m != nil {
+		return m[1] + m[2]
+	}
+	return pair
+}
+
+// KrakenProvider implementa MarketDataProvider usando la API pública de Kraken.
+type KrakenProvider struct{}
+
+func (p *KrakenProvider) Name() string { return "kraken" }
+
+func (p *KrakenProvider) FetchTickers(ctx context.Context) ([]ExchangeTicker, error) {
+	var raw struct {
+		Result map[string]struct {
+			C []string `json:"c"`
+			V []string `json:"v"`
+		} `json:"result"`
+	}
+	if err := httpGetJSON(ctx, "https://api.kraken.com/0/public/Ticker?pair=XBTUSD,ETHUSD", &raw); err != nil {
+		return nil, err
+	}
+
+	tickers := make([]ExchangeTicker, 0, len(raw.Result))
+	for pair, data := range raw.Result {
+		if len(data.C) == 0 {
+			continue
+		}
+		price, err := strconv.ParseFloat(data.C[0], 64)
+		if err != nil {
+			continue
+		}
+		var volume float64
+		if len(data.V) > 1 {
+			volume, _ = strconv.ParseFloat(data.V[1], 64)
+		}
+		tickers = append(tickers, ExchangeTicker{
+			Exchange: p.Name(),
+			Symbol:   normalizeSymbol(stripKrakenAssetPrefixes(pair)),
+			Price:    price,
+			Volume:   volume,
+		})
+	}
+	return tickers, nil
+}
+
+// KuCoinProvider implementa MarketDataProvider usando la API pública de KuCoin.
+type KuCoinProvider struct{}
+
+func (p *KuCoinProvider) Name() string { return "kucoin" }
+
+func (p *KuCoinProvider) FetchTickers(ctx context.Context) ([]ExchangeTicker, error) {
+	var raw struct {
+		Data struct {
+			Ticker []struct {
+				Symbol string `json:"symbol"`
+				Last   string `json:"last"`
+				VolVal string `json:"volValue"`
+			} `json:"ticker"`
+		} `json:"data"`
+	}
+	if err := httpGetJSON(ctx, "https://api.kucoin.com/api/v1/market/allTickers", &raw); err != nil {
+		return nil, err
+	}
+
+	tickers := make([]ExchangeTicker, 0, len(raw.Data.Ticker))
+	for _, item := range raw.Data.Ticker {
+		price, err := strconv.ParseFloat(item.Last, 64)
+		if err != nil {
+			continue
+		}
+		volume, _ := strconv.ParseFloat(item.VolVal, 64)
+		tickers = append(tickers, ExchangeTicker{
+			Exchange: p.Name(),
+			Symbol:   normalizeSymbol(item.Symbol),
+			Price:    price,
+			Volume:   volume,
+		})
+	}
+	return tickers, nil
+}
+
+// BitfinexProvider implementa MarketDataProvider usando la API pública de Bitfinex.
+type BitfinexProvider struct{}
+
+func (p *BitfinexProvider) Name() string { return "bitfinex" }
+
+func (p *BitfinexProvider) FetchTickers(ctx context.Context) ([]ExchangeTicker, error) {
+	var raw [][]interface{}
+	if err := httpGetJSON(ctx, "https://api-pub.bitfinex.com/v2/tickers?symbols=ALL", &raw); err != nil {
+		return nil, err
+	}
+
+	tickers := make([]ExchangeTicker, 0, len(raw))
+	for _, row := range raw {
+		if len(row) < 9 {
+			continue
+		}
+		symbol, ok := row[0].(string)
+		if !ok || !strings.HasPrefix(symbol, "t") {
+			continue
+		}
+		price, ok := row[7].(float64)
+		if !ok {
+			continue
+		}
+		volume, _ := row[8].(float64)
+		tickers = append(tickers, ExchangeTicker{
+			Exchange: p.Name(),
+			Symbol:   normalizeSymbol(strings.TrimPrefix(symbol, "t")),
+			Price:    price,
+			Volume:   volume,
+		})
+	}
+	return tickers, nil
+}
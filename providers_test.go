@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestNormalizeSymbol(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want string
+	}{
+		{"BTCUSDT", "BTC-USDT"},
+		{"ETHUSD", "ETH-USD"},
+		{"BTC/USDT", "BTC-USDT"},
+		{"XBTUSD", "BTC-USD"},
+		{"XBT-USD", "BTC-USD"},
+		{"usdtusd", "USDT-USD"},
+		{"WEIRD", "WEIRD"},
+	}
+
+	for _, tc := range cases {
+		if got := normalizeSymbol(tc.raw); got != tc.want {
+			t.Errorf("normalizeSymbol(%q) = %q, want %q", tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestStripKrakenAssetPrefixes(t *testing.T) {
+	cases := []struct {
+		pair string
+		want string
+	}{
+		{"XXBTZUSD", "XBTUSD"},
+		{"XETHZUSD", "ETHUSD"},
+		{"SOLUSD", "SOLUSD"},
+	}
+
+	for _, tc := range cases {
+		if got := stripKrakenAssetPrefixes(tc.pair); got != tc.want {
+			t.Errorf("stripKrakenAssetPrefixes(%q) = %q, want %q", tc.pair, got, tc.want)
+		}
+	}
+}
+
+func TestStripKrakenAssetPrefixesThenNormalize(t *testing.T) {
+	cases := []struct {
+		pair string
+		want string
+	}{
+		{"XXBTZUSD", "BTC-USD"},
+		{"XETHZUSD", "ETH-USD"},
+	}
+
+	for _, tc := range cases {
+		got := normalizeSymbol(stripKrakenAssetPrefixes(tc.pair))
+		if got != tc.want {
+			t.Errorf("normalizeSymbol(stripKrakenAssetPrefixes(%q)) = %q, want %q", tc.pair, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// HistoryPoint es un punto de la serie temporal devuelta por GET /history,
+// ya sea un snapshot en crudo o un agregado por hora/día.
+type HistoryPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Price     float64   `json:"price"`
+	Change24h float64   `json:"change_24h"`
+}
+
+// SnapshotStore persiste cada ciclo de scraping, sirve el histórico
+// resultante y guarda las reglas de alertas que evalúa el poller. La
+// implementación por defecto es SQLite; Postgres se activa configurando
+// STORE_DRIVER=postgres.
+type SnapshotStore interface {
+	SaveSnapshot(ctx context.Context, items []CryptoInfo, takenAt time.Time) error
+	QueryHistory(ctx context.Context, symbol string, from, to time.Time, granularity string) ([]HistoryPoint, error)
+	Compact(ctx context.Context, retentionDays int) error
+	Close() error
+
+	SaveAlertRule(ctx context.Context, rule AlertRule) error
+	ListAlertRules(ctx context.Context) ([]AlertRule, error)
+	GetAlertRule(ctx context.Context, id string) (AlertRule, error)
+	DeleteAlertRule(ctx context.Context, id string) error
+	MarkAlertFired(ctx context.Context, id string, firedAt time.Time) error
+}
+
+// sqlSnapshotStore implementa SnapshotStore sobre database/sql, válido tanto
+// para SQLite como para Postgres ya que solo usa SQL estándar más el
+// placeholder específico de cada driver.
+type sqlSnapshotStore struct {
+	db     *sql.DB
+	driver string
+}
+
+// NewSnapshotStore abre (y si hace falta crea) el almacén configurado por las
+// variables de entorno STORE_DRIVER ("sqlite" por defecto, o "postgres") y
+// STORE_DSN (ruta de fichero para SQLite, cadena de conexión para Postgres).
+func NewSnapshotStore() (SnapshotStore, error) {
+	driver := strings.ToLower(os.Getenv("STORE_DRIVER"))
+	if driver == "" {
+		driver = "sqlite"
+	}
+
+	dsn := os.Getenv("STORE_DSN")
+
+	var db *sql.DB
+	var err error
+	switch driver {
+	case "sqlite":
+		if dsn == "" {
+			dsn = "./data/market.db"
+			if mkErr := os.MkdirAll("./data", os.ModePerm); mkErr != nil {
+				return nil, fmt.Errorf("creating data directory: %w", mkErr)
+			}
+		}
+		db, err = sql.Open("sqlite", dsn)
+	case "postgres":
+		if dsn == "" {
+			return nil, fmt.Errorf("STORE_DSN es obligatorio cuando STORE_DRIVER=postgres")
+		}
+		db, err = sql.Open("postgres", dsn)
+	default:
+		return nil, fmt.Errorf("STORE_DRIVER desconocido: %s", driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	store := &sqlSnapshotStore{db: db, driver: driver}
+	if err := store.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *sqlSnapshotStore) migrate() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS snapshots (
+			id SERIAL PRIMARY KEY,
+			symbol TEXT NOT NULL,
+			price DOUBLE PRECISION NOT NULL,
+			change_24h DOUBLE PRECISION NOT NULL,
+			recorded_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS snapshots_rollup (
+			symbol TEXT NOT NULL,
+			granularity TEXT NOT NULL,
+			bucket TIMESTAMP NOT NULL,
+			avg_price DOUBLE PRECISION NOT NULL,
+			avg_change_24h DOUBLE PRECISION NOT NULL,
+			PRIMARY KEY (symbol, granularity, bucket)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_snapshots_symbol_time ON snapshots (symbol, recorded_at)`,
+		`CREATE TABLE IF NOT EXISTS alert_rules (
+			id TEXT PRIMARY KEY,
+			symbol TEXT NOT NULL,
+			condition TEXT NOT NULL,
+			webhook_url TEXT NOT NULL DEFAULT '',
+			email TEXT NOT NULL DEFAULT '',
+			telegram_chat_id TEXT NOT NULL DEFAULT '',
+			last_fired_at TIMESTAMP
+		)`,
+	}
+	if s.driver == "sqlite" {
+		statements[0] = strings.Replace(statements[0], "SERIAL", "INTEGER", 1)
+	}
+
+	for _, stmt := range statements {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return fmt.Errorf("running migration: %w", err)
+		}
+	}
+	return nil
+}
+
+// placeholder devuelve el marcador de posición del driver activo para el
+// enésimo parámetro (1-indexado): "?" en SQLite, "$1", "$2"... en Postgres.
+func (s *sqlSnapshotStore) placeholder(n int) string {
+	if s.driver == "postgres" {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// SaveSnapshot inserta un registro por cada CryptoInfo del ciclo de scraping
+// actual, con el precio y el cambio de 24h ya parseados a número.
+func (s *sqlSnapshotStore) SaveSnapshot(ctx context.Context, items []CryptoInfo, takenAt time.Time) error {
+	query := fmt.Sprintf(
+		"INSERT INTO snapshots (symbol, price, change_24h, recorded_at) VALUES (%s, %s, %s, %s)",
+		s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4),
+	)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		price := parseLenientFloat(item.Price)
+		change := parseLenientFloat(strings.TrimSuffix(strings.TrimPrefix(item.Change24h, "+"), "%"))
+		if _, err := tx.ExecContext(ctx, query, item.Name, price, change, takenAt.UTC()); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// QueryHistory devuelve la serie temporal de un símbolo. Con granularity
+// "raw" lee directamente de snapshots; con "1h" o "1d" combina los agregados
+// que ya generó Compact con cubos calculados al vuelo sobre los snapshots en
+// crudo que todavía no se han compactado, para que un rango reciente (dentro
+// de historyRetentionDays) no devuelva vacío.
+func (s *sqlSnapshotStore) QueryHistory(ctx context.Context, symbol string, from, to time.Time, granularity string) ([]HistoryPoint, error) {
+	if granularity == "" || granularity == "raw" {
+		query := fmt.Sprintf(
+			`SELECT recorded_at, price, change_24h FROM snapshots
+			 WHERE symbol = %s AND recorded_at BETWEEN %s AND %s
+			 ORDER BY recorded_at ASC`,
+			s.placeholder(1), s.placeholder(2), s.placeholder(3),
+		)
+		return s.queryPoints(ctx, query, symbol, from.UTC(), to.UTC())
+	}
+
+	query := fmt.Sprintf(
+		`SELECT bucket, avg_price, avg_change_24h FROM snapshots_rollup
+		 WHERE symbol = %s AND granularity = %s AND bucket BETWEEN %s AND %s
+		 ORDER BY bucket ASC`,
+		s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4),
+	)
+	rolledUp, err := s.queryPoints(ctx, query, symbol, granularity, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	onTheFly, err := s.queryRawBucketed(ctx, symbol, granularity, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeHistoryPoints(rolledUp, onTheFly), nil
+}
+
+// queryRawBucketed calcula sobre la marcha los cubos de granularity para el
+// rango pedido a partir de snapshots en crudo, usado para completar los
+// datos recientes que Compact todavía no ha volcado a snapshots_rollup.
+func (s *sqlSnapshotStore) queryRawBucketed(ctx context.Context, symbol, granularity string, from, to time.Time) ([]HistoryPoint, error) {
+	query := fmt.Sprintf(
+		`SELECT recorded_at, price, change_24h FROM snapshots
+		 WHERE symbol = %s AND recorded_at BETWEEN %s AND %s`,
+		s.placeholder(1), s.placeholder(2), s.placeholder(3),
+	)
+	rows, err := s.db.QueryContext(ctx, query, symbol, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type accumulator struct {
+		priceSum, changeSum float64
+		count               int
+	}
+	buckets := make(map[time.Time]*accumulator)
+
+	for rows.Next() {
+		var price, change float64
+		var recordedAt time.Time
+		if err := rows.Scan(&recordedAt, &price, &change); err != nil {
+			return nil, err
+		}
+		bucket := truncateToGranularity(recordedAt, granularity)
+		acc, ok := buckets[bucket]
+		if !ok {
+			acc = &accumulator{}
+			buckets[bucket] = acc
+		}
+		acc.priceSum += price
+		acc.changeSum += change
+		acc.count++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	points := make([]HistoryPoint, 0, len(buckets))
+	for bucket, acc := range buckets {
+		points = append(points, HistoryPoint{
+			Timestamp: bucket,
+			Price:     acc.priceSum / float64(acc.count),
+			Change24h: acc.changeSum / float64(acc.count),
+		})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Timestamp.Before(points[j].Timestamp) })
+	return points, nil
+}
+
+// mergeHistoryPoints combina los cubos ya persistidos en snapshots_rollup con
+// los calculados al vuelo, quedándose con el calculado al vuelo cuando ambos
+// cubren el mismo instante (es el más reciente), y devuelve el resultado
+// ordenado cronológicamente.
+func mergeHistoryPoints(rolledUp, onTheFly []HistoryPoint) []HistoryPoint {
+	merged := make(map[time.Time]HistoryPoint, len(rolledUp)+len(onTheFly))
+	for _, point := range rolledUp {
+		merged[point.Timestamp] = point
+	}
+	for _, point := range onTheFly {
+		merged[point.Timestamp] = point
+	}
+
+	points := make([]HistoryPoint, 0, len(merged))
+	for _, point := range merged {
+		points = append(points, point)
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Timestamp.Before(points[j].Timestamp) })
+	return points
+}
+
+func (s *sqlSnapshotStore) queryPoints(ctx context.Context, query string, args ...interface{}) ([]HistoryPoint, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []HistoryPoint
+	for rows.Next() {
+		var point HistoryPoint
+		if err := rows.Scan(&point.Timestamp, &point.Price, &point.Change24h); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+// Compact conserva los snapshots en crudo de los últimos retentionDays días y
+// convierte todo lo anterior en agregados por hora y por día, para no dejar
+// crecer la tabla de snapshots indefinidamente.
+func (s *sqlSnapshotStore) Compact(ctx context.Context, retentionDays int) error {
+	// El corte se alinea al inicio del día para que ningún cubo horario o
+	// diario quede repartido entre dos compactaciones.
+	cutoff := truncateToGranularity(time.Now().UTC().AddDate(0, 0, -retentionDays), "1d")
+
+	for _, granularity := range []string{"1h", "1d"} {
+		if err := s.rollup(ctx, granularity, cutoff); err != nil {
+			return fmt.Errorf("rolling up %s buckets: %w", granularity, err)
+		}
+	}
+
+	query := fmt.Sprintf("DELETE FROM snapshots WHERE recorded_at < %s", s.placeholder(1))
+	_, err := s.db.ExecContext(ctx, query, cutoff)
+	return err
+}
+
+func (s *sqlSnapshotStore) rollup(ctx context.Context, granularity string, cutoff time.Time) error {
+	rows, err := s.db.QueryContext(ctx,
+		fmt.Sprintf("SELECT symbol, price, change_24h, recorded_at FROM snapshots WHERE recorded_at < %s", s.placeholder(1)),
+		cutoff,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type accumulator struct {
+		priceSum, changeSum float64
+		count               int
+	}
+	buckets := make(map[string]*accumulator)
+
+	for rows.Next() {
+		var symbol string
+		var price, change float64
+		var recordedAt time.Time
+		if err := rows.Scan(&symbol, &price, &change, &recordedAt); err != nil {
+			return err
+		}
+		bucket := truncateToGranularity(recordedAt, granularity)
+		key := symbol + "|" + bucket.Format(time.RFC3339)
+		acc, ok := buckets[key]
+		if !ok {
+			acc = &accumulator{}
+			buckets[key] = acc
+		}
+		acc.priceSum += price
+		acc.changeSum += change
+		acc.count++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	upsert := s.upsertRollupQuery()
+	for key, acc := range buckets {
+		parts := strings.SplitN(key, "|", 2)
+		symbol := parts[0]
+		bucket, err := time.Parse(time.RFC3339, parts[1])
+		if err != nil {
+			continue
+		}
+		avgPrice := acc.priceSum / float64(acc.count)
+		avgChange := acc.changeSum / float64(acc.count)
+		if _, err := s.db.ExecContext(ctx, upsert, symbol, granularity, bucket, avgPrice, avgChange); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlSnapshotStore) upsertRollupQuery() string {
+	if s.driver == "postgres" {
+		return `INSERT INTO snapshots_rollup (symbol, granularity, bucket, avg_price, avg_change_24h)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (symbol, granularity, bucket)
+			DO UPDATE SET avg_price = EXCLUDED.avg_price, avg_change_24h = EXCLUDED.avg_change_24h`
+	}
+	return `INSERT INTO snapshots_rollup (symbol, granularity, bucket, avg_price, avg_change_24h)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (symbol, granularity, bucket)
+		DO UPDATE SET avg_price = excluded.avg_price, avg_change_24h = excluded.avg_change_24h`
+}
+
+func (s *sqlSnapshotStore) Close() error {
+	return s.db.Close()
+}
+
+// SaveAlertRule inserta o actualiza (por ID) una regla de alerta.
+func (s *sqlSnapshotStore) SaveAlertRule(ctx context.Context, rule AlertRule) error {
+	var query string
+	if s.driver == "postgres" {
+		query = `INSERT INTO alert_rules (id, symbol, condition, webhook_url, email, telegram_chat_id)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (id) DO UPDATE SET
+				symbol = EXCLUDED.symbol, condition = EXCLUDED.condition,
+				webhook_url = EXCLUDED.webhook_url, email = EXCLUDED.email,
+				telegram_chat_id = EXCLUDED.telegram_chat_id`
+	} else {
+		query = `INSERT INTO alert_rules (id, symbol, condition, webhook_url, email, telegram_chat_id)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO UPDATE SET
+				symbol = excluded.symbol, condition = excluded.condition,
+				webhook_url = excluded.webhook_url, email = excluded.email,
+				telegram_chat_id = excluded.telegram_chat_id`
+	}
+
+	_, err := s.db.ExecContext(ctx, query, rule.ID, rule.Symbol, rule.Condition, rule.WebhookURL, rule.Email, rule.TelegramChatID)
+	return err
+}
+
+// ListAlertRules devuelve todas las reglas registradas.
+func (s *sqlSnapshotStore) ListAlertRules(ctx context.Context) ([]AlertRule, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, symbol, condition, webhook_url, email, telegram_chat_id, last_fired_at FROM alert_rules`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []AlertRule
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// GetAlertRule busca una regla por ID.
+func (s *sqlSnapshotStore) GetAlertRule(ctx context.Context, id string) (AlertRule, error) {
+	query := fmt.Sprintf(
+		`SELECT id, symbol, condition, webhook_url, email, telegram_chat_id, last_fired_at
+		 FROM alert_rules WHERE id = %s`,
+		s.placeholder(1),
+	)
+	row := s.db.QueryRowContext(ctx, query, id)
+	return scanAlertRule(row)
+}
+
+// DeleteAlertRule borra una regla por ID.
+func (s *sqlSnapshotStore) DeleteAlertRule(ctx context.Context, id string) error {
+	query := fmt.Sprintf("DELETE FROM alert_rules WHERE id = %s", s.placeholder(1))
+	_, err := s.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// MarkAlertFired actualiza el momento del último disparo de una regla, usado
+// para el debouncing: una regla no vuelve a notificar hasta pasado el
+// intervalo mínimo configurado.
+func (s *sqlSnapshotStore) MarkAlertFired(ctx context.Context, id string, firedAt time.Time) error {
+	query := fmt.Sprintf("UPDATE alert_rules SET last_fired_at = %s WHERE id = %s", s.placeholder(1), s.placeholder(2))
+	_, err := s.db.ExecContext(ctx, query, firedAt.UTC(), id)
+	return err
+}
+
+// rowScanner abstrae *sql.Row y *sql.Rows para poder compartir el escaneo de
+// una fila de alert_rules entre GetAlertRule y ListAlertRules.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAlertRule(row rowScanner) (AlertRule, error) {
+	var rule AlertRule
+	var lastFiredAt sql.NullTime
+	err := row.Scan(&rule.ID, &rule.Symbol, &rule.Condition, &rule.WebhookURL, &rule.Email, &rule.TelegramChatID, &lastFiredAt)
+	if err != nil {
+		return AlertRule{}, err
+	}
+	if lastFiredAt.Valid {
+		rule.LastFiredAt = lastFiredAt.Time
+	}
+	return rule, nil
+}
+
+func truncateToGranularity(t time.Time, granularity string) time.Time {
+	t = t.UTC()
+	switch granularity {
+	case "1h":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.UTC)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func parseLenientFloat(raw string) float64 {
+	value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// historyRetentionDays controla cuántos días se conservan en crudo antes de
+// compactar, configurable con HISTORY_RETENTION_DAYS.
+func historyRetentionDays() int {
+	if raw := os.Getenv("HISTORY_RETENTION_DAYS"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return 30
+}
+
+// StartCompactionJob lanza en segundo plano la compactación diaria del
+// histórico según historyRetentionDays.
+func StartCompactionJob(store SnapshotStore) {
+	go func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := store.Compact(context.Background(), historyRetentionDays()); err != nil {
+				log.Printf("Error compacting historical snapshots: %v\n", err)
+			}
+		}
+	}()
+}
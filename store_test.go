@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTruncateToGranularity(t *testing.T) {
+	at := time.Date(2026, 7, 25, 14, 37, 52, 0, time.UTC)
+
+	cases := []struct {
+		granularity string
+		want        time.Time
+	}{
+		{"1h", time.Date(2026, 7, 25, 14, 0, 0, 0, time.UTC)},
+		{"1d", time.Date(2026, 7, 25, 0, 0, 0, 0, time.UTC)},
+		{"unknown", time.Date(2026, 7, 25, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range cases {
+		if got := truncateToGranularity(at, tc.granularity); !got.Equal(tc.want) {
+			t.Errorf("truncateToGranularity(%v, %q) = %v, want %v", at, tc.granularity, got, tc.want)
+		}
+	}
+}
+
+func TestMergeHistoryPointsPrefersOnTheFly(t *testing.T) {
+	bucket := time.Date(2026, 7, 25, 14, 0, 0, 0, time.UTC)
+	rolledUp := []HistoryPoint{{Timestamp: bucket, Price: 100}}
+	onTheFly := []HistoryPoint{{Timestamp: bucket, Price: 105}}
+
+	merged := mergeHistoryPoints(rolledUp, onTheFly)
+	if len(merged) != 1 || merged[0].Price != 105 {
+		t.Errorf("mergeHistoryPoints() = %+v, want a single point with price 105", merged)
+	}
+}